@@ -0,0 +1,54 @@
+package steam
+
+// Logger receives structured log events from the library. Each method
+// takes a human-readable message followed by alternating key/value pairs,
+// mirroring the convention used by log/slog and most structured loggers.
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// Tracer receives low-level protocol events as the library talks to a
+// server: raw sends/receives, challenge handshakes, and RCON frames. It's
+// the ground truth needed to debug the many quirky Source-engine forks
+// (CS:GO, Rust, Squad, Insurgency) that deviate from the canonical
+// protocol.
+//
+// There is deliberately no OnRetry hook: nothing in this package retries a
+// failed send/receive on the caller's behalf, so a retry event would never
+// fire. Add it back alongside whatever introduces retry logic, not before.
+type Tracer interface {
+	// OnSend is called with the kind of packet ("a2s-info", "a2s-player",
+	// "a2s-rules", "rcon", ...) and its raw bytes just before it's written.
+	OnSend(kind string, data []byte)
+	// OnReceive is called with the kind of packet and its raw bytes just
+	// after it's read, including each fragment of a multi-packet response.
+	OnReceive(kind string, data []byte)
+	// OnChallenge is called when a server replies with an A2S challenge.
+	OnChallenge(challenge int32)
+	// OnRCONFrame is called for every decoded RCON response frame.
+	OnRCONFrame(id, typ int32, size int)
+}
+
+// defaultLogger and defaultTracer are used whenever ConnectOptions doesn't
+// supply its own, so the instrumented call sites never have to nil-check.
+var (
+	defaultLogger Logger = noopLogger{}
+	defaultTracer Tracer = noopTracer{}
+)
+
+type noopLogger struct{}
+
+func (noopLogger) Debug(string, ...interface{}) {}
+func (noopLogger) Info(string, ...interface{})  {}
+func (noopLogger) Warn(string, ...interface{})  {}
+func (noopLogger) Error(string, ...interface{}) {}
+
+type noopTracer struct{}
+
+func (noopTracer) OnSend(string, []byte)         {}
+func (noopTracer) OnReceive(string, []byte)      {}
+func (noopTracer) OnChallenge(int32)             {}
+func (noopTracer) OnRCONFrame(int32, int32, int) {}
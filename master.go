@@ -0,0 +1,248 @@
+package steam
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Master server regions, as documented by Valve's Master Server Query
+// Protocol. Pass one of these to MasterServer.Query.
+const (
+	RegionUSEast       byte = 0x00
+	RegionUSWest       byte = 0x01
+	RegionSouthAmerica byte = 0x02
+	RegionEurope       byte = 0x03
+	RegionAsia         byte = 0x04
+	RegionAustralia    byte = 0x05
+	RegionMiddleEast   byte = 0x06
+	RegionAfrica       byte = 0x07
+	RegionAll          byte = 0xFF
+)
+
+const masterServerSentinel = "0.0.0.0:0"
+
+// MasterServer queries Valve's Master Server Query Protocol
+// (hl2master.steampowered.com:27011 by default) to enumerate live Source
+// engine servers.
+type MasterServer struct {
+	usock *udpSocket
+}
+
+// NewMasterServer connects to the master server at addr, e.g.
+// "hl2master.steampowered.com:27011".
+func NewMasterServer(addr string, opts ...*ConnectOptions) (*MasterServer, error) {
+	var o *ConnectOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	dial := DialFn(nil)
+	if o != nil {
+		dial = o.Dial
+	}
+	if dial == nil && o != nil && o.Proxy != nil {
+		dial = o.Proxy.Dial
+	}
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 1 * time.Second}).Dial
+	}
+	usock, err := newUDPSocket(dial, addr, o)
+	if err != nil {
+		return nil, fmt.Errorf("steam: could not open udp socket (%v)", err)
+	}
+	return &MasterServer{usock: usock}, nil
+}
+
+// Close releases the resources associated with this master server
+// connection.
+func (m *MasterServer) Close() {
+	m.usock.close()
+}
+
+// Query starts iterating the servers the master server knows about in
+// region that match filter. Pass a nil filter to match every server in the
+// region.
+func (m *MasterServer) Query(region byte, filter *Filter) *ServerIterator {
+	return &ServerIterator{
+		master: m,
+		region: region,
+		filter: filter,
+		last:   masterServerSentinel,
+	}
+}
+
+// Filter builds a master server query filter out of the well-known keys
+// documented by Valve's Master Server Query Protocol.
+type Filter struct {
+	buf bytes.Buffer
+}
+
+// NewFilter returns an empty Filter.
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// GameDir restricts results to servers running the given game (e.g. "cstrike").
+func (f *Filter) GameDir(dir string) *Filter {
+	return f.Raw("gamedir", dir)
+}
+
+// Map restricts results to servers running the given map.
+func (f *Filter) Map(name string) *Filter {
+	return f.Raw("map", name)
+}
+
+// Empty restricts results to servers that are not empty.
+func (f *Filter) Empty() *Filter {
+	return f.Raw("empty", "1")
+}
+
+// Secure restricts results to VAC-secured servers.
+func (f *Filter) Secure() *Filter {
+	return f.Raw("secure", "1")
+}
+
+// AppID restricts results to servers running the given Steam app id.
+func (f *Filter) AppID(id int) *Filter {
+	return f.Raw("appid", strconv.Itoa(id))
+}
+
+// NotAppID excludes servers running the given Steam app id.
+func (f *Filter) NotAppID(id int) *Filter {
+	return f.Raw("napp", strconv.Itoa(id))
+}
+
+// GameType restricts results to servers tagged with all of the given
+// sv_tags values.
+func (f *Filter) GameType(tags ...string) *Filter {
+	return f.Raw("gametype", strings.Join(tags, ","))
+}
+
+// GameData restricts results to servers advertising all of the given
+// AppID-specific data tags (L4D2 only).
+func (f *Filter) GameData(tags ...string) *Filter {
+	return f.Raw("gamedata", strings.Join(tags, ","))
+}
+
+// Raw adds an arbitrary \key\value pair to the filter, for keys not
+// covered by the named helpers above.
+func (f *Filter) Raw(key, value string) *Filter {
+	f.buf.WriteByte('\\')
+	f.buf.WriteString(key)
+	f.buf.WriteByte('\\')
+	f.buf.WriteString(value)
+	return f
+}
+
+func (f *Filter) marshalBinary() []byte {
+	if f == nil {
+		return nil
+	}
+	return f.buf.Bytes()
+}
+
+// ServerIterator iterates the servers returned by MasterServer.Query,
+// transparently issuing follow-up requests seeded with the last address
+// received until the protocol's 0.0.0.0:0 sentinel is reached.
+type ServerIterator struct {
+	master *MasterServer
+	region byte
+	filter *Filter
+
+	last  string
+	addrs []string
+	idx   int
+	done  bool
+	err   error
+}
+
+// Next advances the iterator, fetching another batch of addresses from the
+// master server as needed. It returns false once the server list is
+// exhausted or an error occurred; check Err to tell them apart.
+func (it *ServerIterator) Next() bool {
+	for it.idx >= len(it.addrs) {
+		if it.done {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			it.err = err
+			it.done = true
+			return false
+		}
+	}
+	it.idx++
+	return true
+}
+
+// Addr returns the "ip:port" address of the server at the iterator's
+// current position. Only valid after a call to Next that returned true.
+func (it *ServerIterator) Addr() string {
+	return it.addrs[it.idx-1]
+}
+
+// Err returns the first error encountered while iterating, if any.
+func (it *ServerIterator) Err() error {
+	return it.err
+}
+
+func (it *ServerIterator) fetch() error {
+	var buf bytes.Buffer
+	writeByte(&buf, 0x31)
+	writeByte(&buf, it.region)
+	buf.WriteString(it.last)
+	writeNull(&buf)
+	buf.Write(it.filter.marshalBinary())
+	writeNull(&buf)
+
+	if err := it.master.usock.send(buf.Bytes()); err != nil {
+		return err
+	}
+	data, err := it.master.usock.receive()
+	if err != nil {
+		return err
+	}
+	addrs, err := parseMasterServerResponse(data)
+	if err != nil {
+		return err
+	}
+	if n := len(addrs); n > 0 && addrs[n-1] == masterServerSentinel {
+		addrs = addrs[:n-1]
+		it.done = true
+	} else if n > 0 {
+		it.last = addrs[n-1]
+	} else {
+		it.done = true
+	}
+	it.addrs = addrs
+	it.idx = 0
+	return nil
+}
+
+// parseMasterServerResponse decodes the \xFF\xFF\xFF\xFF\x66\x0A header
+// followed by a flat array of six-byte (4-byte IPv4 + 2-byte port)
+// big-endian address entries.
+func parseMasterServerResponse(data []byte) (addrs []string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	buf := bytes.NewReader(data)
+	readBytes(buf, 4) // 0xFFFFFFFF
+	if h := readByte(buf); h != 0x66 {
+		return nil, errBadData
+	}
+	if h := readByte(buf); h != 0x0A {
+		return nil, errBadData
+	}
+	for buf.Len() >= 6 {
+		ip := net.IP(readBytes(buf, 4))
+		portBytes := readBytes(buf, 2)
+		port := int(portBytes[0])<<8 | int(portBytes[1])
+		addrs = append(addrs, fmt.Sprintf("%s:%d", ip.String(), port))
+	}
+	return addrs, nil
+}
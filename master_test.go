@@ -0,0 +1,189 @@
+package steam
+
+import (
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestFilter_Raw(t *testing.T) {
+	f := NewFilter().Raw("foo", "bar")
+	if got, want := string(f.marshalBinary()), `\foo\bar`; got != want {
+		t.Fatalf("marshalBinary() = %q, want %q", got, want)
+	}
+}
+
+func TestFilter_NamedHelpersChainAndEncode(t *testing.T) {
+	f := NewFilter().
+		GameDir("cstrike").
+		Map("de_dust2").
+		Empty().
+		Secure().
+		AppID(240).
+		NotAppID(10).
+		GameType("increased_maxplayers", "alltalk")
+
+	want := `\gamedir\cstrike\map\de_dust2\empty\1\secure\1\appid\240\napp\10\gametype\increased_maxplayers,alltalk`
+	if got := string(f.marshalBinary()); got != want {
+		t.Fatalf("marshalBinary() = %q, want %q", got, want)
+	}
+}
+
+func TestFilter_GameData(t *testing.T) {
+	f := NewFilter().GameData("no_roles", "versus")
+	if got, want := string(f.marshalBinary()), `\gamedata\no_roles,versus`; got != want {
+		t.Fatalf("marshalBinary() = %q, want %q", got, want)
+	}
+}
+
+func TestFilter_NilMarshalsToNil(t *testing.T) {
+	var f *Filter
+	if got := f.marshalBinary(); got != nil {
+		t.Fatalf("marshalBinary() = %v, want nil", got)
+	}
+}
+
+// buildMasterServerResponse encodes the \xFF\xFF\xFF\xFF\x66\x0A header
+// followed by the given "ip:port" entries, matching the wire format
+// parseMasterServerResponse expects.
+func buildMasterServerResponse(t *testing.T, addrs ...string) []byte {
+	t.Helper()
+	data := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x66, 0x0A}
+	for _, addr := range addrs {
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			t.Fatalf("SplitHostPort(%q): %v", addr, err)
+		}
+		ip := net.ParseIP(host).To4()
+		if ip == nil {
+			t.Fatalf("not an IPv4 address: %q", host)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			t.Fatalf("parse port %q: %v", portStr, err)
+		}
+		data = append(data, ip...)
+		data = append(data, byte(port>>8), byte(port))
+	}
+	return data
+}
+
+func TestParseMasterServerResponse_DecodesAddresses(t *testing.T) {
+	data := buildMasterServerResponse(t, "1.2.3.4:27015", "5.6.7.8:27016")
+	addrs, err := parseMasterServerResponse(data)
+	if err != nil {
+		t.Fatalf("parseMasterServerResponse: %v", err)
+	}
+	want := []string{"1.2.3.4:27015", "5.6.7.8:27016"}
+	if len(addrs) != len(want) {
+		t.Fatalf("got %v, want %v", addrs, want)
+	}
+	for i := range want {
+		if addrs[i] != want[i] {
+			t.Fatalf("addrs[%d] = %q, want %q", i, addrs[i], want[i])
+		}
+	}
+}
+
+func TestParseMasterServerResponse_EmptyBatch(t *testing.T) {
+	addrs, err := parseMasterServerResponse(buildMasterServerResponse(t))
+	if err != nil {
+		t.Fatalf("parseMasterServerResponse: %v", err)
+	}
+	if len(addrs) != 0 {
+		t.Fatalf("addrs = %v, want empty", addrs)
+	}
+}
+
+func TestParseMasterServerResponse_BadHeader(t *testing.T) {
+	data := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00}
+	if _, err := parseMasterServerResponse(data); err == nil {
+		t.Fatal("parseMasterServerResponse: expected error for bad header, got nil")
+	}
+}
+
+func TestParseMasterServerResponse_IgnoresTrailingPartialEntry(t *testing.T) {
+	data := buildMasterServerResponse(t, "1.2.3.4:27015")
+	data = append(data, 1, 2, 3) // a trailing partial (< 6 byte) entry
+	addrs, err := parseMasterServerResponse(data)
+	if err != nil {
+		t.Fatalf("parseMasterServerResponse: %v", err)
+	}
+	if want := []string{"1.2.3.4:27015"}; len(addrs) != 1 || addrs[0] != want[0] {
+		t.Fatalf("addrs = %v, want %v", addrs, want)
+	}
+}
+
+// fakeMasterServer answers UDP master-server queries with a scripted
+// sequence of responses, one per request received, so ServerIterator's
+// pagination and sentinel handling can be tested end to end.
+func fakeMasterServer(t *testing.T, responses [][]byte) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 2048)
+		for _, resp := range responses {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if _, err := conn.WriteTo(resp, addr); err != nil {
+				return
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestServerIterator_PaginatesUntilSentinel(t *testing.T) {
+	page1 := buildMasterServerResponse(t, "1.1.1.1:27015", "2.2.2.2:27015")
+	page2 := buildMasterServerResponse(t, "3.3.3.3:27015", masterServerSentinel)
+	addr := fakeMasterServer(t, [][]byte{page1, page2})
+
+	m, err := NewMasterServer(addr)
+	if err != nil {
+		t.Fatalf("NewMasterServer: %v", err)
+	}
+	defer m.Close()
+
+	it := m.Query(RegionAll, nil)
+	var got []string
+	for it.Next() {
+		got = append(got, it.Addr())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator error: %v", err)
+	}
+	want := []string{"1.1.1.1:27015", "2.2.2.2:27015", "3.3.3.3:27015"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestServerIterator_EmptyFirstBatchStopsImmediately(t *testing.T) {
+	addr := fakeMasterServer(t, [][]byte{buildMasterServerResponse(t)})
+
+	m, err := NewMasterServer(addr)
+	if err != nil {
+		t.Fatalf("NewMasterServer: %v", err)
+	}
+	defer m.Close()
+
+	it := m.Query(RegionAll, nil)
+	if it.Next() {
+		t.Fatalf("Next() = true, want false on an empty first batch")
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iterator error: %v", err)
+	}
+}
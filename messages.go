@@ -0,0 +1,199 @@
+package steam
+
+import (
+	"bytes"
+)
+
+const (
+	reqInfo       byte = 'T'
+	reqPlayer     byte = 'U'
+	reqRules      byte = 0x56
+	respChallenge byte = 0x41
+	respInfo      byte = 0x49
+	respPlayer    byte = 0x44
+	respRules     byte = 0x45
+)
+
+// isChallengeResponse reports whether data is an A2S_SERVERQUERY_GETCHALLENGE
+// (0x41) reply.
+func isChallengeResponse(data []byte) bool {
+	return len(data) >= 5 && data[4] == respChallenge
+}
+
+// ChallengeResponse is sent by the server in place of the requested reply
+// when a challenge number is required.
+type ChallengeResponse struct {
+	Challenge int32
+}
+
+func (r *ChallengeResponse) unmarshalBinary(data []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = rec.(error)
+		}
+	}()
+	buf := bytes.NewReader(data)
+	readBytes(buf, 4) // 0xFFFFFFFF
+	if h := readByte(buf); h != respChallenge {
+		return errBadData
+	}
+	r.Challenge = readLong(buf)
+	return nil
+}
+
+type infoRequest struct {
+	challenge int32
+}
+
+func (r infoRequest) marshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeRequestPrefix(&buf)
+	writeByte(&buf, reqInfo)
+	buf.WriteString("Source Engine Query")
+	writeNull(&buf)
+	if r.challenge != 0 {
+		writeLong(&buf, r.challenge)
+	}
+	return buf.Bytes(), nil
+}
+
+// InfoResponse holds the information returned by an A2S_INFO query.
+type InfoResponse struct {
+	Protocol    byte
+	Name        string
+	Map         string
+	Folder      string
+	Game        string
+	ID          int16
+	Players     byte
+	MaxPlayers  byte
+	Bots        byte
+	ServerType  byte
+	Environment byte
+	Visibility  byte
+	VAC         byte
+	Version     string
+}
+
+func (r *InfoResponse) unmarshalBinary(data []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = rec.(error)
+		}
+	}()
+	buf := bytes.NewReader(data)
+	readBytes(buf, 4) // 0xFFFFFFFF
+	if h := readByte(buf); h != respInfo {
+		return errBadData
+	}
+	r.Protocol = readByte(buf)
+	r.Name = readString(buf)
+	r.Map = readString(buf)
+	r.Folder = readString(buf)
+	r.Game = readString(buf)
+	r.ID = readShort(buf)
+	r.Players = readByte(buf)
+	r.MaxPlayers = readByte(buf)
+	r.Bots = readByte(buf)
+	r.ServerType = readByte(buf)
+	r.Environment = readByte(buf)
+	r.Visibility = readByte(buf)
+	r.VAC = readByte(buf)
+	r.Version = readString(buf)
+	return nil
+}
+
+type playersInfoRequest struct {
+	challenge int32
+}
+
+func (r playersInfoRequest) marshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeRequestPrefix(&buf)
+	writeByte(&buf, reqPlayer)
+	if r.challenge != 0 {
+		writeLong(&buf, r.challenge)
+	} else {
+		writeLong(&buf, -1)
+	}
+	return buf.Bytes(), nil
+}
+
+// Player describes a single player entry in a PlayersInfoResponse.
+type Player struct {
+	Index    byte
+	Name     string
+	Score    int32
+	Duration float32
+}
+
+// PlayersInfoResponse holds the information returned by an A2S_PLAYER query.
+type PlayersInfoResponse struct {
+	Players []Player
+}
+
+func (r *PlayersInfoResponse) unmarshalBinary(data []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = rec.(error)
+		}
+	}()
+	buf := bytes.NewReader(data)
+	readBytes(buf, 4) // 0xFFFFFFFF
+	if h := readByte(buf); h != respPlayer {
+		return errBadData
+	}
+	count := readByte(buf)
+	r.Players = make([]Player, 0, count)
+	for i := byte(0); i < count; i++ {
+		r.Players = append(r.Players, Player{
+			Index:    readByte(buf),
+			Name:     readString(buf),
+			Score:    readLong(buf),
+			Duration: readFloat(buf),
+		})
+	}
+	return nil
+}
+
+type rulesRequest struct {
+	challenge int32
+}
+
+func (r rulesRequest) marshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeRequestPrefix(&buf)
+	writeByte(&buf, reqRules)
+	if r.challenge != 0 {
+		writeLong(&buf, r.challenge)
+	} else {
+		writeLong(&buf, -1)
+	}
+	return buf.Bytes(), nil
+}
+
+// RulesResponse holds the cvars returned by an A2S_RULES query.
+type RulesResponse struct {
+	Rules map[string]string
+}
+
+func (r *RulesResponse) unmarshalBinary(data []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = rec.(error)
+		}
+	}()
+	buf := bytes.NewReader(data)
+	readBytes(buf, 4) // 0xFFFFFFFF
+	if h := readByte(buf); h != respRules {
+		return errBadData
+	}
+	count := readShort(buf)
+	r.Rules = make(map[string]string, count)
+	for i := int16(0); i < count; i++ {
+		key := readString(buf)
+		value := readString(buf)
+		r.Rules[key] = value
+	}
+	return nil
+}
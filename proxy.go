@@ -0,0 +1,333 @@
+package steam
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Proxier dials a TCP or UDP connection to addr through a proxy server. It
+// has the same shape as DialFn so a Proxier's Dial method can be used
+// anywhere a DialFn is expected.
+type Proxier interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// SOCKS5Proxy dials through a SOCKS5 proxy (RFC 1928), supporting both the
+// CONNECT command for the RCON/TCP transport and UDP ASSOCIATE for the A2S
+// UDP transport. Username and Password enable RFC 1929 authentication; leave
+// both empty to use the "no authentication" method.
+type SOCKS5Proxy struct {
+	// Addr is the address (host:port) of the SOCKS5 proxy.
+	Addr string
+
+	Username string
+	Password string
+}
+
+// NewSOCKS5Proxy returns a SOCKS5Proxy that dials through the proxy at addr.
+func NewSOCKS5Proxy(addr string) *SOCKS5Proxy {
+	return &SOCKS5Proxy{Addr: addr}
+}
+
+const (
+	socks5Version = 0x05
+
+	socks5MethodNoAuth         = 0x00
+	socks5MethodUserPass       = 0x02
+	socks5MethodNoneAcceptable = 0xFF
+
+	socks5CmdConnect      = 0x01
+	socks5CmdUDPAssociate = 0x03
+
+	socks5AddrIPv4   = 0x01
+	socks5AddrDomain = 0x03
+	socks5AddrIPv6   = 0x04
+)
+
+// Dial implements Proxier.
+func (p *SOCKS5Proxy) Dial(network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp":
+		return p.dialTCP(addr)
+	case "udp":
+		return p.dialUDP(addr)
+	default:
+		return nil, fmt.Errorf("steam: socks5 proxy does not support network %q", network)
+	}
+}
+
+func (p *SOCKS5Proxy) dialTCP(addr string) (net.Conn, error) {
+	ctrl, err := net.DialTimeout("tcp", p.Addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.handshake(ctrl); err != nil {
+		_ = ctrl.Close()
+		return nil, err
+	}
+	if _, err := p.request(ctrl, socks5CmdConnect, addr); err != nil {
+		_ = ctrl.Close()
+		return nil, err
+	}
+	return ctrl, nil
+}
+
+func (p *SOCKS5Proxy) dialUDP(addr string) (net.Conn, error) {
+	ctrl, err := net.DialTimeout("tcp", p.Addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	closeCtrl := true
+	defer func() {
+		if closeCtrl {
+			_ = ctrl.Close()
+		}
+	}()
+	if err := p.handshake(ctrl); err != nil {
+		return nil, err
+	}
+	// The bound address doubles as the relay we must send datagrams to.
+	relayAddr, err := p.request(ctrl, socks5CmdUDPAssociate, "0.0.0.0:0")
+	if err != nil {
+		return nil, err
+	}
+	if relayAddr.IP.IsUnspecified() {
+		// Some proxies reply with 0.0.0.0 and expect the client to use the
+		// control connection's own remote address instead.
+		if host, _, err := net.SplitHostPort(ctrl.RemoteAddr().String()); err == nil {
+			if ip := net.ParseIP(host); ip != nil {
+				relayAddr.IP = ip
+			}
+		}
+	}
+	target, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	relay, err := net.Dial("udp", relayAddr.String())
+	if err != nil {
+		return nil, err
+	}
+	closeCtrl = false
+	return &socks5UDPConn{relay: relay, ctrl: ctrl, target: target}, nil
+}
+
+// handshake performs the RFC 1928 method negotiation, falling back to RFC
+// 1929 username/password authentication when the proxy requires it.
+func (p *SOCKS5Proxy) handshake(conn net.Conn) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	methods := []byte{socks5MethodNoAuth}
+	if p.Username != "" {
+		methods = []byte{socks5MethodUserPass, socks5MethodNoAuth}
+	}
+	greeting := append([]byte{socks5Version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	resp := readBytes(conn, 2)
+	if resp[0] != socks5Version {
+		return fmt.Errorf("steam: unexpected socks5 version %d", resp[0])
+	}
+	switch resp[1] {
+	case socks5MethodNoAuth:
+		return nil
+	case socks5MethodUserPass:
+		return p.authenticate(conn)
+	case socks5MethodNoneAcceptable:
+		return fmt.Errorf("steam: socks5 proxy rejected all authentication methods")
+	default:
+		return fmt.Errorf("steam: socks5 proxy chose unsupported method %d", resp[1])
+	}
+}
+
+func (p *SOCKS5Proxy) authenticate(conn net.Conn) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	var buf []byte
+	buf = append(buf, 0x01, byte(len(p.Username)))
+	buf = append(buf, p.Username...)
+	buf = append(buf, byte(len(p.Password)))
+	buf = append(buf, p.Password...)
+	if _, err := conn.Write(buf); err != nil {
+		return err
+	}
+	resp := readBytes(conn, 2)
+	if resp[1] != 0x00 {
+		return fmt.Errorf("steam: socks5 authentication failed")
+	}
+	return nil
+}
+
+// request sends a SOCKS5 request for cmd/addr and returns the bound address
+// from the reply.
+func (p *SOCKS5Proxy) request(conn net.Conn, cmd byte, addr string) (_ *net.UDPAddr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	var port int
+	if _, err := fmt.Sscanf(portStr, "%d", &port); err != nil {
+		return nil, err
+	}
+
+	var buf []byte
+	buf = append(buf, socks5Version, cmd, 0x00)
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		buf = append(buf, socks5AddrIPv4)
+		buf = append(buf, ip.To4()...)
+	} else if ip != nil {
+		buf = append(buf, socks5AddrIPv6)
+		buf = append(buf, ip.To16()...)
+	} else {
+		buf = append(buf, socks5AddrDomain, byte(len(host)))
+		buf = append(buf, host...)
+	}
+	buf = append(buf, byte(port>>8), byte(port))
+	if _, err := conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	head := readBytes(conn, 4)
+	if head[0] != socks5Version {
+		return nil, fmt.Errorf("steam: unexpected socks5 version %d", head[0])
+	}
+	if head[1] != 0x00 {
+		return nil, fmt.Errorf("steam: socks5 proxy refused request (code %d)", head[1])
+	}
+	var ip net.IP
+	switch head[3] {
+	case socks5AddrIPv4:
+		ip = net.IP(readBytes(conn, 4))
+	case socks5AddrIPv6:
+		ip = net.IP(readBytes(conn, 16))
+	case socks5AddrDomain:
+		n := readByte(conn)
+		_ = readBytes(conn, int(n))
+		ip = net.IPv4zero
+	default:
+		return nil, errBadData
+	}
+	portBytes := readBytes(conn, 2)
+	bound := &net.UDPAddr{IP: ip, Port: int(portBytes[0])<<8 | int(portBytes[1])}
+	return bound, nil
+}
+
+// socks5UDPHeaderLen is the size of the RFC 1928 section 7 UDP request
+// header for an IPv4 destination: RSV(2) + FRAG(1) + ATYP(1) + ADDR(4) + PORT(2).
+const socks5UDPHeaderLen = 10
+
+// socks5UDPConn adapts a UDP connection to the proxy's relay address into a
+// net.Conn that transparently prepends/strips the SOCKS5 UDP request header
+// on every datagram. The TCP control connection is kept open for the
+// lifetime of the adapter, since closing it tears down the association.
+type socks5UDPConn struct {
+	relay  net.Conn
+	ctrl   net.Conn
+	target *net.UDPAddr
+}
+
+func (c *socks5UDPConn) Write(b []byte) (int, error) {
+	header := make([]byte, socks5UDPHeaderLen)
+	header[3] = socks5AddrIPv4
+	copy(header[4:8], c.target.IP.To4())
+	header[8] = byte(c.target.Port >> 8)
+	header[9] = byte(c.target.Port)
+	if _, err := c.relay.Write(append(header, b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *socks5UDPConn) Read(b []byte) (int, error) {
+	packet := make([]byte, len(b)+socks5UDPHeaderLen)
+	n, err := c.relay.Read(packet)
+	if err != nil {
+		return 0, err
+	}
+	if n < socks5UDPHeaderLen {
+		return 0, errNotEnoughDataInResponse
+	}
+	return copy(b, packet[socks5UDPHeaderLen:n]), nil
+}
+
+func (c *socks5UDPConn) Close() error {
+	_ = c.ctrl.Close()
+	return c.relay.Close()
+}
+
+func (c *socks5UDPConn) LocalAddr() net.Addr  { return c.relay.LocalAddr() }
+func (c *socks5UDPConn) RemoteAddr() net.Addr { return c.target }
+
+func (c *socks5UDPConn) SetDeadline(t time.Time) error      { return c.relay.SetDeadline(t) }
+func (c *socks5UDPConn) SetReadDeadline(t time.Time) error  { return c.relay.SetReadDeadline(t) }
+func (c *socks5UDPConn) SetWriteDeadline(t time.Time) error { return c.relay.SetWriteDeadline(t) }
+
+// HTTPConnectProxy dials through an HTTP proxy using the CONNECT method.
+// Only the TCP (RCON) transport can be proxied this way; dialing "udp"
+// returns an error.
+type HTTPConnectProxy struct {
+	// Addr is the address (host:port) of the HTTP proxy.
+	Addr string
+
+	Username string
+	Password string
+}
+
+// NewHTTPConnectProxy returns an HTTPConnectProxy that dials through the
+// proxy at addr.
+func NewHTTPConnectProxy(addr string) *HTTPConnectProxy {
+	return &HTTPConnectProxy{Addr: addr}
+}
+
+// Dial implements Proxier.
+func (p *HTTPConnectProxy) Dial(network, addr string) (net.Conn, error) {
+	if network != "tcp" {
+		return nil, fmt.Errorf("steam: http connect proxy does not support network %q", network)
+	}
+	conn, err := net.DialTimeout("tcp", p.Addr, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if p.Username != "" {
+		creds := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = conn.Close()
+		return nil, fmt.Errorf("steam: http connect proxy returned %q", resp.Status)
+	}
+	return conn, nil
+}
@@ -0,0 +1,284 @@
+package steam
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeSOCKS5Server accepts a single connection and performs just enough of
+// RFC 1928 to exercise SOCKS5Proxy: method negotiation (optionally requiring
+// username/password auth) followed by one CONNECT or UDP ASSOCIATE request,
+// replied to with boundAddr. After the handshake it echoes whatever it reads
+// back to the client, so callers can verify the tunnel actually carries data.
+type fakeSOCKS5Server struct {
+	ln net.Listener
+
+	requireAuth bool
+	boundAddr   net.IP
+	boundPort   int
+
+	gotUsername, gotPassword string
+}
+
+func newFakeSOCKS5Server(t *testing.T) *fakeSOCKS5Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSOCKS5Server{ln: ln, boundAddr: net.IPv4(127, 0, 0, 1), boundPort: 1}
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *fakeSOCKS5Server) addr() string { return s.ln.Addr().String() }
+
+func (s *fakeSOCKS5Server) serveOnce(t *testing.T) {
+	t.Helper()
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_ = readByte(conn) // version
+	nmethods := readByte(conn)
+	methods := readBytes(conn, int(nmethods))
+	method := byte(socks5MethodNoAuth)
+	if s.requireAuth {
+		method = socks5MethodUserPass
+	}
+	found := false
+	for _, m := range methods {
+		if m == method {
+			found = true
+		}
+	}
+	if !found {
+		_, _ = conn.Write([]byte{socks5Version, socks5MethodNoneAcceptable})
+		return
+	}
+	_, _ = conn.Write([]byte{socks5Version, method})
+
+	if s.requireAuth {
+		_ = readByte(conn) // auth version
+		ulen := readByte(conn)
+		s.gotUsername = string(readBytes(conn, int(ulen)))
+		plen := readByte(conn)
+		s.gotPassword = string(readBytes(conn, int(plen)))
+		_, _ = conn.Write([]byte{0x01, 0x00})
+	}
+
+	head := readBytes(conn, 4)
+	cmd := head[1]
+	switch head[3] {
+	case socks5AddrIPv4:
+		_ = readBytes(conn, 4)
+	case socks5AddrIPv6:
+		_ = readBytes(conn, 16)
+	case socks5AddrDomain:
+		n := readByte(conn)
+		_ = readBytes(conn, int(n))
+	}
+	_ = readBytes(conn, 2) // port
+
+	reply := []byte{socks5Version, 0x00, 0x00, socks5AddrIPv4}
+	reply = append(reply, s.boundAddr.To4()...)
+	reply = append(reply, byte(s.boundPort>>8), byte(s.boundPort))
+	if _, err := conn.Write(reply); err != nil {
+		return
+	}
+
+	if cmd == socks5CmdUDPAssociate {
+		// The association stays open until the client closes it; just
+		// block until then so the test's Close() call is observed.
+		_, _ = io.Copy(io.Discard, conn)
+		return
+	}
+	_, _ = io.Copy(conn, conn)
+}
+
+func TestSOCKS5Proxy_DialTCP_NoAuthEchoesThroughTunnel(t *testing.T) {
+	srv := newFakeSOCKS5Server(t)
+	go srv.serveOnce(t)
+
+	p := NewSOCKS5Proxy(srv.addr())
+	conn, err := p.Dial("tcp", "game.example:27015")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != "ping" {
+		t.Fatalf("got %q, want %q", got, "ping")
+	}
+}
+
+func TestSOCKS5Proxy_DialTCP_UserPassAuth(t *testing.T) {
+	srv := newFakeSOCKS5Server(t)
+	srv.requireAuth = true
+	go srv.serveOnce(t)
+
+	p := NewSOCKS5Proxy(srv.addr())
+	p.Username, p.Password = "alice", "s3cret"
+	conn, err := p.Dial("tcp", "game.example:27015")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if srv.gotUsername != "alice" || srv.gotPassword != "s3cret" {
+		t.Fatalf("server saw username=%q password=%q, want alice/s3cret", srv.gotUsername, srv.gotPassword)
+	}
+}
+
+func TestSOCKS5Proxy_DialUDP_FallsBackToControlAddrOnUnspecifiedRelay(t *testing.T) {
+	// A real UDP relay the fake proxy "associates" the client with.
+	relay, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	defer relay.Close()
+	relayPort := relay.LocalAddr().(*net.UDPAddr).Port
+
+	srv := newFakeSOCKS5Server(t)
+	srv.boundAddr = net.IPv4zero // proxies that expect the client to substitute ctrl's address
+	srv.boundPort = relayPort
+	go srv.serveOnce(t)
+
+	p := NewSOCKS5Proxy(srv.addr())
+	conn, err := p.Dial("udp", "203.0.113.10:27015")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if conn.RemoteAddr().String() != "203.0.113.10:27015" {
+		t.Fatalf("RemoteAddr = %v, want 203.0.113.10:27015", conn.RemoteAddr())
+	}
+	udpConn, ok := conn.(*socks5UDPConn)
+	if !ok {
+		t.Fatalf("conn is %T, want *socks5UDPConn", conn)
+	}
+	relayHost, _, err := net.SplitHostPort(udpConn.relay.RemoteAddr().String())
+	if err != nil {
+		t.Fatalf("SplitHostPort: %v", err)
+	}
+	if relayHost != "127.0.0.1" {
+		t.Fatalf("relay dialed %q, want the control connection's address (127.0.0.1), not the proxy's literal 0.0.0.0 reply", relayHost)
+	}
+}
+
+func TestSocks5UDPConn_WriteRead_HeaderRoundTrip(t *testing.T) {
+	// An echo server standing in for the proxy's UDP relay: whatever bytes
+	// arrive (header + payload) are sent straight back.
+	echoConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen udp: %v", err)
+	}
+	defer echoConn.Close()
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := echoConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if _, err := echoConn.WriteTo(buf[:n], addr); err != nil {
+				return
+			}
+		}
+	}()
+
+	relay, err := net.Dial("udp", echoConn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("dial udp: %v", err)
+	}
+	defer relay.Close()
+
+	target := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 27015}
+	conn := &socks5UDPConn{relay: relay, target: target}
+	// ctrl is only touched by Close, which this test doesn't exercise.
+
+	payload := []byte("ping")
+	if _, err := conn.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline: %v", err)
+	}
+	got := make([]byte, len(payload))
+	n, err := conn.Read(got)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if n != len(payload) || !bytes.Equal(got[:n], payload) {
+		t.Fatalf("Read = %q, want %q (header should be prepended on write and stripped on read)", got[:n], payload)
+	}
+}
+
+func TestHTTPConnectProxy_Dial(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect {
+			_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+			return
+		}
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			return
+		}
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	p := NewHTTPConnectProxy(ln.Addr().String())
+	conn, err := p.Dial("tcp", "game.example:27015")
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	got := make([]byte, 4)
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != "ping" {
+		t.Fatalf("got %q, want %q", got, "ping")
+	}
+}
+
+func TestHTTPConnectProxy_Dial_RejectsUDP(t *testing.T) {
+	p := NewHTTPConnectProxy("127.0.0.1:0")
+	if _, err := p.Dial("udp", "game.example:27015"); err == nil {
+		t.Fatal("Dial(\"udp\", ...): expected error, got nil")
+	}
+}
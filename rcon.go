@@ -0,0 +1,72 @@
+package steam
+
+import (
+	"bytes"
+	"sync/atomic"
+)
+
+// RCON packet types, as defined by the Source RCON Protocol.
+const (
+	rrtAuth      int32 = 3
+	rrtAuthResp  int32 = 2
+	rrtExecCmd   int32 = 2
+	rrtRespValue int32 = 0
+)
+
+var rconRequestID int32
+
+// nextRCONRequestID returns a monotonically increasing id suitable for
+// use as an RCON request id.
+func nextRCONRequestID() int32 {
+	return atomic.AddInt32(&rconRequestID, 1)
+}
+
+type rconRequest struct {
+	id   int32
+	typ  int32
+	body string
+}
+
+func newRCONRequest(typ int32, body string) rconRequest {
+	return rconRequest{
+		id:   nextRCONRequestID(),
+		typ:  typ,
+		body: body,
+	}
+}
+
+func (r rconRequest) marshalBinary() ([]byte, error) {
+	var body bytes.Buffer
+	writeLong(&body, r.id)
+	writeLong(&body, r.typ)
+	writeString(&body, r.body)
+	writeNull(&body)
+
+	var buf bytes.Buffer
+	writeLong(&buf, int32(body.Len()))
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+type rconResponse struct {
+	id   int32
+	typ  int32
+	body []byte
+}
+
+func (r *rconResponse) unmarshalBinary(data []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = rec.(error)
+		}
+	}()
+	buf := bytes.NewReader(data)
+	size := readLong(buf)
+	r.id = readLong(buf)
+	r.typ = readLong(buf)
+	// size counts id and type (8 bytes), the body and its two trailing NULs.
+	// The body is read raw, not as a string, since the end-of-response
+	// trailer itself contains NUL bytes.
+	r.body = readBytes(buf, int(size)-10)
+	return nil
+}
@@ -2,40 +2,217 @@ package steam
 
 import (
 	"bytes"
-	"fmt"
+	"context"
 	"io"
 	"net"
+	"sync"
 	"time"
 )
 
+// defaultRCONReadTimeout is the deadline applied to each pending command
+// while waiting for its next frame when the caller's context carries no
+// deadline of its own.
+const defaultRCONReadTimeout = 400 * time.Millisecond
+
+// defaultRCONWriteTimeout is the deadline applied to each write on the
+// RCON TCP connection when the caller's context carries no deadline of
+// its own.
+const defaultRCONWriteTimeout = 400 * time.Millisecond
+
+// rconFrame is a decoded RCON response frame, or the terminal error that
+// ended the connection's read loop.
+type rconFrame struct {
+	resp rconResponse
+	err  error
+}
+
+// rconPending is the bookkeeping behind a single registered command: the
+// channel its frames are delivered on, and a done signal closed once the
+// caller has stopped reading from it (e.g. because its context was
+// canceled), so dispatch never blocks forever on an abandoned command.
+type rconPending struct {
+	ch        chan rconFrame
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// rconSocket multiplexes the RCON TCP connection: a single background
+// goroutine decodes frames off the wire and dispatches each one to the
+// channel registered for its request id, so multiple commands can be
+// in flight on the same connection at once.
 type rconSocket struct {
 	conn net.Conn
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	writeMu sync.Mutex
+
+	mu       sync.Mutex
+	pending  map[int32]*rconPending
+	closeErr error
+
+	tracer Tracer
 }
 
-func newRCONSocket(dial DialFn, addr string) (*rconSocket, error) {
+func newRCONSocket(dial DialFn, addr string, opts ...*ConnectOptions) (*rconSocket, error) {
 	conn, err := dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
-	return &rconSocket{conn}, nil
+	s := &rconSocket{
+		conn:         conn,
+		readTimeout:  defaultRCONReadTimeout,
+		writeTimeout: defaultRCONWriteTimeout,
+		pending:      make(map[int32]*rconPending),
+		tracer:       defaultTracer,
+	}
+	if len(opts) > 0 && opts[0] != nil {
+		if d := opts[0].RCONReadTimeout; d > 0 {
+			s.readTimeout = d
+		}
+		if d := opts[0].RCONWriteTimeout; d > 0 {
+			s.writeTimeout = d
+		}
+		if t := opts[0].Tracer; t != nil {
+			s.tracer = t
+		}
+	}
+	go s.readLoop()
+	return s, nil
 }
 
 func (s *rconSocket) close() {
 	_ = s.conn.Close()
 }
 
-func (s *rconSocket) send(p []byte) error {
-	if err := s.conn.SetWriteDeadline(time.Now().Add(400 * time.Millisecond)); err != nil {
+// register allocates a channel that will receive every frame the read loop
+// decodes for any of ids, until unregister is called for it.
+func (s *rconSocket) register(ids ...int32) (chan rconFrame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		return nil, s.closeErr
+	}
+	p := &rconPending{ch: make(chan rconFrame), done: make(chan struct{})}
+	for _, id := range ids {
+		s.pending[id] = p
+	}
+	return p.ch, nil
+}
+
+// unregister marks ids as abandoned, so a dispatch racing with this call
+// drops the frame instead of blocking on a channel nobody reads anymore.
+func (s *rconSocket) unregister(ids ...int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if p, ok := s.pending[id]; ok {
+			p.closeOnce.Do(func() { close(p.done) })
+			delete(s.pending, id)
+		}
+	}
+}
+
+// send writes an RCON request frame to the connection. Writes from
+// concurrent commands are serialized so frames are never interleaved.
+func (s *rconSocket) send(ctx context.Context, p []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(s.writeTimeout)
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
 		return err
 	}
+	stop := watchContextWrite(ctx, s.conn)
+	defer stop()
+	s.tracer.OnSend("rcon", p)
 	_, err := s.conn.Write(p)
-	if err != nil {
-		return err
+	return err
+}
+
+// next blocks until a frame arrives on ch for the in-flight command that
+// registered it, ctx is done, or no frame arrives within readTimeout (only
+// when ctx carries no deadline of its own).
+func (s *rconSocket) next(ctx context.Context, ch chan rconFrame) (rconResponse, error) {
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timer := time.NewTimer(s.readTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case f := <-ch:
+		return f.resp, f.err
+	case <-ctx.Done():
+		return rconResponse{}, ctx.Err()
+	case <-timeout:
+		return rconResponse{}, context.DeadlineExceeded
 	}
-	return nil
 }
 
-func (s *rconSocket) receive() (_ []byte, err error) {
+// readLoop decodes frames off the wire for as long as the connection is
+// alive, dispatching each to its registered request id. It exits, and fails
+// every pending command, the first time a read or decode fails.
+func (s *rconSocket) readLoop() {
+	for {
+		data, err := s.readFrame()
+		if err != nil {
+			s.failAll(err)
+			return
+		}
+		s.tracer.OnReceive("rcon", data)
+		var resp rconResponse
+		if err := resp.unmarshalBinary(data); err != nil {
+			s.failAll(err)
+			return
+		}
+		s.tracer.OnRCONFrame(resp.id, resp.typ, len(resp.body))
+		s.dispatch(resp)
+	}
+}
+
+func (s *rconSocket) dispatch(resp rconResponse) {
+	s.mu.Lock()
+	p, ok := s.pending[resp.id]
+	s.mu.Unlock()
+	if !ok {
+		// Nobody is waiting on this id (e.g. a duplicate or unsolicited
+		// frame); nothing to do but drop it.
+		return
+	}
+	// If the caller abandoned this request (e.g. its context was canceled
+	// and SendContext's deferred unregister already ran), don't block the
+	// one shared read loop forever waiting for a reader that's gone.
+	select {
+	case p.ch <- rconFrame{resp: resp}:
+	case <-p.done:
+	}
+}
+
+func (s *rconSocket) failAll(err error) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.closeErr = err
+	s.mu.Unlock()
+
+	notified := make(map[*rconPending]bool, len(pending))
+	for _, p := range pending {
+		if notified[p] {
+			continue
+		}
+		notified[p] = true
+		select {
+		case p.ch <- rconFrame{err: err}:
+		case <-p.done:
+		}
+	}
+}
+
+func (s *rconSocket) readFrame() (_ []byte, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = r.(error)
@@ -46,22 +223,52 @@ func (s *rconSocket) receive() (_ []byte, err error) {
 	total := int(readLong(tr))
 	for total > 0 {
 		b := make([]byte, total)
-		if err := s.conn.SetReadDeadline(time.Now().Add(400 * time.Millisecond)); err != nil {
-			return nil, err
-		}
 		n, err := s.conn.Read(b)
 		if n > 0 {
-			_, err := buf.Write(b)
-			if err != nil {
-				return nil, err
+			if _, werr := buf.Write(b[:n]); werr != nil {
+				return nil, werr
 			}
 			total -= n
 		}
 		if err != nil {
-			if err == io.EOF {
-				return nil, fmt.Errorf("steam: could not receive data (%v)", err)
-			}
+			return nil, err
 		}
 	}
 	return buf.Bytes(), nil
 }
+
+// watchContext aborts any in-flight read/write on conn as soon as ctx is
+// done, by forcing an immediate deadline. The returned stop func must be
+// called once the operation it guards has completed.
+func watchContext(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// watchContextWrite is like watchContext but only forces the write
+// deadline, leaving any read in progress on the same (possibly shared and
+// multiplexed) connection undisturbed.
+func watchContextWrite(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetWriteDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,82 @@
+package steam
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestRCONSocket() *rconSocket {
+	return &rconSocket{
+		pending:      make(map[int32]*rconPending),
+		readTimeout:  defaultRCONReadTimeout,
+		writeTimeout: defaultWriteTimeout,
+		tracer:       defaultTracer,
+	}
+}
+
+func TestRCONSocket_DispatchRoundTrip(t *testing.T) {
+	s := newTestRCONSocket()
+	ch, err := s.register(1)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	defer s.unregister(1)
+
+	go s.dispatch(rconResponse{id: 1, typ: rrtRespValue, body: []byte("ok")})
+
+	resp, err := s.next(context.Background(), ch)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if string(resp.body) != "ok" {
+		t.Fatalf("resp.body = %q, want %q", resp.body, "ok")
+	}
+}
+
+// TestRCONSocket_DispatchDoesNotBlockOnAbandonedRequest reproduces the
+// deadlock where a frame dispatched for a request whose caller has already
+// stopped reading (e.g. its context was canceled, and the deferred
+// unregister ran) would block the single shared read loop forever, wedging
+// every other in-flight command on the connection.
+func TestRCONSocket_DispatchDoesNotBlockOnAbandonedRequest(t *testing.T) {
+	s := newTestRCONSocket()
+	if _, err := s.register(5); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	s.mu.Lock()
+	p := s.pending[5]
+	s.mu.Unlock()
+	// Simulate unregister (triggered by the caller's context being
+	// canceled) racing with dispatch: the pending entry is still visible
+	// to dispatch's lookup, but nobody will ever read from p.ch again.
+	p.closeOnce.Do(func() { close(p.done) })
+
+	done := make(chan struct{})
+	go func() {
+		s.dispatch(rconResponse{id: 5, typ: rrtRespValue, body: []byte("late")})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("dispatch blocked forever on an abandoned request")
+	}
+}
+
+func TestRCONSocket_UnregisterRemovesAllSharedIDs(t *testing.T) {
+	s := newTestRCONSocket()
+	if _, err := s.register(1, 2); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	s.unregister(1, 2)
+
+	s.mu.Lock()
+	_, ok1 := s.pending[1]
+	_, ok2 := s.pending[2]
+	s.mu.Unlock()
+	if ok1 || ok2 {
+		t.Fatalf("expected both ids removed after unregister, got ok1=%v ok2=%v", ok1, ok2)
+	}
+}
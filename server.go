@@ -2,6 +2,7 @@ package steam
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -16,6 +17,7 @@ type Server struct {
 	addr string
 
 	dial DialFn
+	opts *ConnectOptions
 
 	rconPassword string
 
@@ -25,6 +27,9 @@ type Server struct {
 	rsock           *rconSocket
 	rconInitialized bool
 
+	logger Logger
+	tracer Tracer
+
 	mu sync.Mutex
 }
 
@@ -36,17 +41,63 @@ type ConnectOptions struct {
 
 	// RCON password.
 	RCONPassword string
+
+	// MaxFragments bounds how many fragments a single multi-packet A2S
+	// response (e.g. a large Rules() reply) may be split across before
+	// it is rejected. Defaults to defaultMaxFragments.
+	MaxFragments int
+
+	// FragmentTimeout bounds how long to wait for the remaining fragments
+	// of a multi-packet A2S response. Defaults to defaultFragmentTimeout.
+	FragmentTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout bound each A2S UDP read/write when the
+	// call isn't made through one of the *Context methods with its own
+	// deadline. Both default to 400ms.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// RCONReadTimeout and RCONWriteTimeout bound each read from and write
+	// to the RCON TCP connection when the call isn't made through one of
+	// the *Context methods with its own deadline. Both default to 400ms.
+	RCONReadTimeout  time.Duration
+	RCONWriteTimeout time.Duration
+
+	// Proxy, when set, routes both the RCON/TCP and A2S/UDP transports
+	// through the given proxy instead of dialing the server directly.
+	// Ignored if Dial is also set.
+	Proxy Proxier
+
+	// Logger receives structured log events. Defaults to a no-op logger;
+	// see NewSlogLogger to wire up log/slog.
+	Logger Logger
+
+	// Tracer receives low-level protocol events (raw sends/receives,
+	// challenges, RCON frames). Defaults to a no-op tracer.
+	Tracer Tracer
 }
 
 // Connect to the source server.
 func Connect(addr string, os ...*ConnectOptions) (_ *Server, err error) {
 	s := &Server{
-		addr: addr,
+		addr:   addr,
+		logger: defaultLogger,
+		tracer: defaultTracer,
 	}
 	if len(os) > 0 {
 		o := os[0]
 		s.dial = o.Dial
 		s.rconPassword = o.RCONPassword
+		s.opts = o
+		if o.Logger != nil {
+			s.logger = o.Logger
+		}
+		if o.Tracer != nil {
+			s.tracer = o.Tracer
+		}
+	}
+	if s.dial == nil && s.opts != nil && s.opts.Proxy != nil {
+		s.dial = s.opts.Proxy.Dial
 	}
 	if s.dial == nil {
 		s.dial = (&net.Dialer{
@@ -79,7 +130,7 @@ func (s *Server) init() error {
 		return errors.New("steam: server needs a address")
 	}
 	var err error
-	if s.usock, err = newUDPSocket(s.dial, s.addr); err != nil {
+	if s.usock, err = newUDPSocket(s.dial, s.addr, s.opts); err != nil {
 		return fmt.Errorf("steam: could not open udp socket (%v)", err)
 	}
 	return nil
@@ -89,7 +140,7 @@ func (s *Server) initRCON() (err error) {
 	if s.addr == "" {
 		return errors.New("steam: server needs a address")
 	}
-	if s.rsock, err = newRCONSocket(s.dial, s.addr); err != nil {
+	if s.rsock, err = newRCONSocket(s.dial, s.addr, s.opts); err != nil {
 		return fmt.Errorf("steam: could not open tcp socket (%v)", err)
 	}
 	defer func() {
@@ -105,20 +156,25 @@ func (s *Server) initRCON() (err error) {
 }
 
 func (s *Server) authenticate() error {
+	ctx := context.Background()
 	req := newRCONRequest(rrtAuth, s.rconPassword)
+	// A failed authentication is answered with id -1, so that id is routed
+	// here too.
+	ch, err := s.rsock.register(req.id, -1)
+	if err != nil {
+		return err
+	}
+	defer s.rsock.unregister(req.id, -1)
+
 	data, _ := req.marshalBinary()
-	if err := s.rsock.send(data); err != nil {
+	if err := s.rsock.send(ctx, data); err != nil {
 		return err
 	}
 	// Receive the empty response value
-	data, err := s.rsock.receive()
+	resp, err := s.rsock.next(ctx, ch)
 	if err != nil {
 		return err
 	}
-	var resp rconResponse
-	if err := resp.unmarshalBinary(data); err != nil {
-		return err
-	}
 	if resp.typ != rrtRespValue || resp.id != req.id {
 		return ErrInvalidResponseID
 	}
@@ -126,13 +182,10 @@ func (s *Server) authenticate() error {
 		return ErrInvalidResponseType
 	}
 	// Receive the actual auth response
-	data, err = s.rsock.receive()
+	resp, err = s.rsock.next(ctx, ch)
 	if err != nil {
 		return err
 	}
-	if err := resp.unmarshalBinary(data); err != nil {
-		return err
-	}
 	if resp.typ != rrtAuthResp || resp.id != req.id {
 		return ErrRCONAuthFailed
 	}
@@ -149,12 +202,18 @@ func (s *Server) Close() {
 
 // Ping returns the RTT (round-trip time) to the server.
 func (s *Server) Ping() (time.Duration, error) {
+	return s.PingContext(context.Background())
+}
+
+// PingContext is like Ping but carries a context that bounds the request
+// and can be used to cancel it early.
+func (s *Server) PingContext(ctx context.Context) (time.Duration, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	req, _ := infoRequest{}.marshalBinary()
 	start := time.Now()
-	_ = s.usock.send(req)
-	if _, err := s.usock.receive(); err != nil {
+	_ = s.usock.sendContext(ctx, req)
+	if _, err := s.usock.receiveContext(ctx); err != nil {
 		return 0, err
 	}
 	elapsed := time.Since(start)
@@ -163,16 +222,24 @@ func (s *Server) Ping() (time.Duration, error) {
 
 // Info retrieves server information.
 func (s *Server) Info() (*InfoResponse, error) {
+	return s.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but carries a context that bounds the request
+// and can be used to cancel it early.
+func (s *Server) InfoContext(ctx context.Context) (*InfoResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	req, _ := infoRequest{}.marshalBinary()
-	if err := s.usock.send(req); err != nil {
+	s.tracer.OnSend("a2s-info", req)
+	if err := s.usock.sendContext(ctx, req); err != nil {
 		return nil, err
 	}
-	data, err := s.usock.receive()
+	data, err := s.usock.receiveContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("steam: could not receive info response (%v)", err)
 	}
+	s.tracer.OnReceive("a2s-info", data)
 
 	if isChallengeResponse(data) {
 		// Parse the challenge response
@@ -180,15 +247,18 @@ func (s *Server) Info() (*InfoResponse, error) {
 		if err := challangeRes.unmarshalBinary(data); err != nil {
 			return nil, err
 		}
+		s.tracer.OnChallenge(challangeRes.Challenge)
 		// Send a new request with the proper challenge number
 		req, _ = infoRequest{challangeRes.Challenge}.marshalBinary()
-		if err := s.usock.send(req); err != nil {
+		s.tracer.OnSend("a2s-info", req)
+		if err := s.usock.sendContext(ctx, req); err != nil {
 			return nil, err
 		}
-		data, err = s.usock.receive()
+		data, err = s.usock.receiveContext(ctx)
 		if err != nil {
 			return nil, err
 		}
+		s.tracer.OnReceive("a2s-info", data)
 	}
 
 	var res InfoResponse
@@ -200,32 +270,43 @@ func (s *Server) Info() (*InfoResponse, error) {
 
 // PlayersInfo retrieves player information from the server.
 func (s *Server) PlayersInfo() (*PlayersInfoResponse, error) {
+	return s.PlayersInfoContext(context.Background())
+}
+
+// PlayersInfoContext is like PlayersInfo but carries a context that bounds
+// the request and can be used to cancel it early.
+func (s *Server) PlayersInfoContext(ctx context.Context) (*PlayersInfoResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	// Send the challenge request
 	req, _ := playersInfoRequest{}.marshalBinary()
-	if err := s.usock.send(req); err != nil {
+	s.tracer.OnSend("a2s-player", req)
+	if err := s.usock.sendContext(ctx, req); err != nil {
 		return nil, err
 	}
-	data, err := s.usock.receive()
+	data, err := s.usock.receiveContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	s.tracer.OnReceive("a2s-player", data)
 	if isChallengeResponse(data) {
 		// Parse the challenge response
 		var challangeRes ChallengeResponse
 		if err := challangeRes.unmarshalBinary(data); err != nil {
 			return nil, err
 		}
+		s.tracer.OnChallenge(challangeRes.Challenge)
 		// Send a new request with the proper challenge number
 		req, _ = playersInfoRequest{challangeRes.Challenge}.marshalBinary()
-		if err := s.usock.send(req); err != nil {
+		s.tracer.OnSend("a2s-player", req)
+		if err := s.usock.sendContext(ctx, req); err != nil {
 			return nil, err
 		}
-		data, err = s.usock.receive()
+		data, err = s.usock.receiveContext(ctx)
 		if err != nil {
 			return nil, err
 		}
+		s.tracer.OnReceive("a2s-player", data)
 	}
 	// Parse the return value
 	var res PlayersInfoResponse
@@ -235,22 +316,85 @@ func (s *Server) PlayersInfo() (*PlayersInfoResponse, error) {
 	return &res, nil
 }
 
-// Send RCON command to the server.
-func (s *Server) Send(cmd string) (string, error) {
+// Rules retrieves the server's cvars via an A2S_RULES query, returned as a
+// map of rule name to value.
+func (s *Server) Rules() (map[string]string, error) {
+	return s.RulesContext(context.Background())
+}
+
+// RulesContext is like Rules but carries a context that bounds the request
+// and can be used to cancel it early.
+func (s *Server) RulesContext(ctx context.Context) (map[string]string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	// Send the challenge request
+	req, _ := rulesRequest{}.marshalBinary()
+	s.tracer.OnSend("a2s-rules", req)
+	if err := s.usock.sendContext(ctx, req); err != nil {
+		return nil, err
+	}
+	data, err := s.usock.receiveContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.tracer.OnReceive("a2s-rules", data)
+	if isChallengeResponse(data) {
+		// Parse the challenge response
+		var challangeRes ChallengeResponse
+		if err := challangeRes.unmarshalBinary(data); err != nil {
+			return nil, err
+		}
+		s.tracer.OnChallenge(challangeRes.Challenge)
+		// Send a new request with the proper challenge number
+		req, _ = rulesRequest{challangeRes.Challenge}.marshalBinary()
+		s.tracer.OnSend("a2s-rules", req)
+		if err := s.usock.sendContext(ctx, req); err != nil {
+			return nil, err
+		}
+		data, err = s.usock.receiveContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.tracer.OnReceive("a2s-rules", data)
+	}
+	// Parse the return value
+	var res RulesResponse
+	if err := res.unmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return res.Rules, nil
+}
+
+// Send RCON command to the server.
+func (s *Server) Send(cmd string) (string, error) {
+	return s.SendContext(context.Background(), cmd)
+}
+
+// SendContext is like Send but carries a context that bounds the request
+// and can be used to cancel it early. Unlike the A2S methods, SendContext
+// does not serialize on the server's lock: the underlying rconSocket
+// multiplexes commands by request id, so multiple SendContext calls can be
+// in flight concurrently on the same connection.
+func (s *Server) SendContext(ctx context.Context, cmd string) (string, error) {
 	if !s.rconInitialized {
 		return "", ErrRCONNotInitialized
 	}
+	s.logger.Debug("steam: sending rcon command", "cmd", cmd)
 	req := newRCONRequest(rrtExecCmd, cmd)
+	reqMirror := newRCONRequest(rrtRespValue, "")
+	ch, err := s.rsock.register(req.id, reqMirror.id)
+	if err != nil {
+		return "", err
+	}
+	defer s.rsock.unregister(req.id, reqMirror.id)
+
 	data, _ := req.marshalBinary()
-	if err := s.rsock.send(data); err != nil {
+	if err := s.rsock.send(ctx, data); err != nil {
 		return "", fmt.Errorf("steam: sending rcon request (%v)", err)
 	}
 	// Send the mirror packet.
-	reqMirror := newRCONRequest(rrtRespValue, "")
-	data, _ = reqMirror.marshalBinary()
-	if err := s.rsock.send(data); err != nil {
+	mirrorData, _ := reqMirror.marshalBinary()
+	if err := s.rsock.send(ctx, mirrorData); err != nil {
 		return "", fmt.Errorf("steam: sending rcon mirror request (%v)", err)
 	}
 	var (
@@ -259,15 +403,12 @@ func (s *Server) Send(cmd string) (string, error) {
 	)
 	// Start receiving data.
 	for {
-		data, err := s.rsock.receive()
+		resp, err := s.rsock.next(ctx, ch)
 		if err != nil {
 			return "", fmt.Errorf("steam: receiving rcon response (%v)", err)
 		}
-		var resp rconResponse
-		if err := resp.unmarshalBinary(data); err != nil {
-			return "", fmt.Errorf("steam: decoding response (%v)", err)
-		}
 		if resp.typ != rrtRespValue {
+			s.logger.Warn("steam: unexpected rcon response type", "id", resp.id, "typ", resp.typ)
 			return "", ErrInvalidResponseType
 		}
 		if !sawMirror && resp.id == reqMirror.id {
@@ -278,9 +419,11 @@ func (s *Server) Send(cmd string) (string, error) {
 			if bytes.Compare(resp.body, trailer) == 0 {
 				break
 			}
+			s.logger.Warn("steam: unexpected rcon response trailer", "id", resp.id, "body", resp.body)
 			return "", ErrInvalidResponseTrailer
 		}
 		if req.id != resp.id {
+			s.logger.Warn("steam: unexpected rcon response id", "want", req.id, "got", resp.id)
 			return "", ErrInvalidResponseID
 		}
 		_, err = buf.Write(resp.body)
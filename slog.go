@@ -0,0 +1,22 @@
+package steam
+
+import "log/slog"
+
+// SlogLogger adapts a *slog.Logger to the Logger interface, so callers on
+// Go 1.21+ get structured JSON (or text) logs for free.
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger wraps l as a Logger. Pass nil to use slog.Default().
+func NewSlogLogger(l *slog.Logger) *SlogLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &SlogLogger{l: l}
+}
+
+func (s *SlogLogger) Debug(msg string, kv ...interface{}) { s.l.Debug(msg, kv...) }
+func (s *SlogLogger) Info(msg string, kv ...interface{})  { s.l.Info(msg, kv...) }
+func (s *SlogLogger) Warn(msg string, kv ...interface{})  { s.l.Warn(msg, kv...) }
+func (s *SlogLogger) Error(msg string, kv ...interface{}) { s.l.Error(msg, kv...) }
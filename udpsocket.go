@@ -0,0 +1,265 @@
+package steam
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// defaultMaxFragments bounds how many fragments a single multi-packet
+// response may be split across, guarding against a misbehaving server
+// that never sends a complete set.
+const defaultMaxFragments = 16
+
+// defaultFragmentTimeout bounds how long udpSocket.receive will wait for
+// the remaining fragments of a multi-packet response.
+const defaultFragmentTimeout = 2 * time.Second
+
+// defaultReadTimeout and defaultWriteTimeout are the per-call deadlines
+// applied when the caller does not supply a context deadline or override
+// ConnectOptions.ReadTimeout/WriteTimeout.
+const (
+	defaultReadTimeout  = 400 * time.Millisecond
+	defaultWriteTimeout = 400 * time.Millisecond
+)
+
+var splitPrefix = []byte{0xFE, 0xFF, 0xFF, 0xFF}
+
+type udpSocket struct {
+	conn net.Conn
+
+	maxFragments    int
+	fragmentTimeout time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+
+	logger Logger
+	tracer Tracer
+}
+
+func newUDPSocket(dial DialFn, addr string, opts ...*ConnectOptions) (*udpSocket, error) {
+	conn, err := dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &udpSocket{
+		conn:            conn,
+		maxFragments:    defaultMaxFragments,
+		fragmentTimeout: defaultFragmentTimeout,
+		readTimeout:     defaultReadTimeout,
+		writeTimeout:    defaultWriteTimeout,
+		logger:          defaultLogger,
+		tracer:          defaultTracer,
+	}
+	if len(opts) > 0 && opts[0] != nil {
+		if n := opts[0].MaxFragments; n > 0 {
+			s.maxFragments = n
+		}
+		if d := opts[0].FragmentTimeout; d > 0 {
+			s.fragmentTimeout = d
+		}
+		if d := opts[0].ReadTimeout; d > 0 {
+			s.readTimeout = d
+		}
+		if d := opts[0].WriteTimeout; d > 0 {
+			s.writeTimeout = d
+		}
+		if l := opts[0].Logger; l != nil {
+			s.logger = l
+		}
+		if t := opts[0].Tracer; t != nil {
+			s.tracer = t
+		}
+	}
+	return s, nil
+}
+
+func (s *udpSocket) close() {
+	_ = s.conn.Close()
+}
+
+func (s *udpSocket) send(p []byte) error {
+	return s.sendContext(context.Background(), p)
+}
+
+func (s *udpSocket) sendContext(ctx context.Context, p []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(s.writeTimeout)
+	}
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	stop := watchContext(ctx, s.conn)
+	defer stop()
+	_, err := s.conn.Write(p)
+	return err
+}
+
+// fragment is a single piece of a multi-packet A2S response.
+type fragment struct {
+	number     byte
+	total      byte
+	compressed bool
+	decompSize uint32
+	decompCRC  uint32
+	payload    []byte
+}
+
+// receive reads a single A2S response from the socket, transparently
+// reassembling multi-packet (0xFFFFFFFE) responses and bzip2-decompressing
+// them when the server marks them as compressed.
+func (s *udpSocket) receive() ([]byte, error) {
+	return s.receiveContext(context.Background())
+}
+
+func (s *udpSocket) receiveContext(ctx context.Context) ([]byte, error) {
+	// Until the first fragment arrives the read is bounded by ctx's deadline,
+	// or by readTimeout when ctx has none; after it, by fragmentTimeout.
+	ctxDeadline, hasCtxDeadline := ctx.Deadline()
+	deadline := time.Now().Add(s.readTimeout)
+	if hasCtxDeadline {
+		deadline = ctxDeadline
+	}
+	fragments := make(map[byte]fragment)
+	var id int32
+	var haveID bool
+
+	stop := watchContext(ctx, s.conn)
+	defer stop()
+
+	for {
+		if err := s.conn.SetReadDeadline(deadlineOrNow(deadline, s.readTimeout)); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 16*1024)
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && time.Now().Before(deadline) {
+				// Only this step's read deadline elapsed, not the overall
+				// fragment/ctx deadline: keep waiting for the response (or
+				// the rest of a multi-packet one) instead of aborting early.
+				continue
+			}
+			return nil, err
+		}
+		data := buf[:n]
+		if len(data) < 4 {
+			s.logger.Warn("steam: truncated a2s response", "len", len(data))
+			return nil, errNotEnoughDataInResponse
+		}
+		switch {
+		case bytes.Equal(data[:4], requestPrefix):
+			return data, nil
+		case bytes.Equal(data[:4], splitPrefix):
+			s.tracer.OnReceive("a2s-fragment", data)
+			f, fid, err := parseFragment(data)
+			if err != nil {
+				s.logger.Warn("steam: unexpected fragment header", "err", err)
+				return nil, err
+			}
+			if !haveID {
+				id, haveID = fid, true
+				deadline = time.Now().Add(s.fragmentTimeout)
+				if hasCtxDeadline && ctxDeadline.Before(deadline) {
+					deadline = ctxDeadline
+				}
+			} else if fid != id {
+				// Fragment belongs to a stale/previous request, ignore it.
+				continue
+			}
+			fragments[f.number] = f
+			if len(fragments) > s.maxFragments {
+				return nil, fmt.Errorf("steam: too many fragments in response (%d)", len(fragments))
+			}
+			if byte(len(fragments)) >= f.total {
+				return reassembleFragments(fragments)
+			}
+		default:
+			s.logger.Warn("steam: unknown a2s response byte", "prefix", data[:4])
+			return nil, errBadData
+		}
+	}
+}
+
+func deadlineOrNow(fragmentDeadline time.Time, step time.Duration) time.Time {
+	stepDeadline := time.Now().Add(step)
+	if stepDeadline.Before(fragmentDeadline) {
+		return stepDeadline
+	}
+	return fragmentDeadline
+}
+
+// parseFragment decodes the header that follows the 0xFFFFFFFE prefix:
+// a 32-bit request ID followed by a packed total/number byte (or, for the
+// compressed variant, an additional decompressed size and CRC32).
+func parseFragment(data []byte) (f fragment, id int32, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	r := bytes.NewReader(data[4:])
+	id = readLong(r)
+	packed := readByte(r)
+	f.total = packed >> 4
+	f.number = packed & 0x0F
+	if f.total == 0 {
+		// Newer engines (CS:GO, CS2, Rust, Squad, Insurgency, ...) lay
+		// total/number out as separate bytes instead of packing them into a
+		// nibble each, with no split-size field following (that field only
+		// ever existed on protocol <= 7, long obsolete).
+		f.total = packed
+		f.number = readByte(r)
+	}
+	// The decompressed size and CRC32 are only sent once per response, on
+	// packet number 0, not repeated on every fragment.
+	if id < 0 && f.number == 0 {
+		f.compressed = true
+		f.decompSize = readULong(r)
+		f.decompCRC = readULong(r)
+	}
+	rest, _ := io.ReadAll(r)
+	f.payload = rest
+	return f, id, nil
+}
+
+func reassembleFragments(fragments map[byte]fragment) ([]byte, error) {
+	ordered := make([]fragment, 0, len(fragments))
+	for _, f := range fragments {
+		ordered = append(ordered, f)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].number < ordered[j].number })
+
+	var buf bytes.Buffer
+	for _, f := range ordered {
+		buf.Write(f.payload)
+	}
+	payload := buf.Bytes()
+	if !ordered[0].compressed {
+		return payload, nil
+	}
+	decompressed, err := io.ReadAll(bzip2.NewReader(bytes.NewReader(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("steam: could not bzip2 decompress response (%v)", err)
+	}
+	f := ordered[0]
+	if uint32(len(decompressed)) != f.decompSize {
+		return nil, fmt.Errorf("steam: decompressed size mismatch (got %d, want %d)", len(decompressed), f.decompSize)
+	}
+	if crc32.ChecksumIEEE(decompressed) != f.decompCRC {
+		return nil, errBadData
+	}
+	return decompressed, nil
+}
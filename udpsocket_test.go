@@ -0,0 +1,149 @@
+package steam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+)
+
+// buildModernFragment encodes a single fragment using the modern
+// (CS:GO/CS2/Rust/Squad/Insurgency) layout: a separate total byte and
+// number byte, with no split-size field trailing them.
+func buildModernFragment(id int32, total, number byte, payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(splitPrefix)
+	must(binary.Write(&buf, binary.LittleEndian, id))
+	buf.WriteByte(total)
+	buf.WriteByte(number)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func TestParseFragment_ModernFormatKeepsFullPayload(t *testing.T) {
+	payload := []byte("AAAAAAAAAA")
+	data := buildModernFragment(1, 3, 1, payload)
+
+	f, id, err := parseFragment(data)
+	if err != nil {
+		t.Fatalf("parseFragment: %v", err)
+	}
+	if id != 1 || f.total != 3 || f.number != 1 {
+		t.Fatalf("got id=%d total=%d number=%d, want id=1 total=3 number=1", id, f.total, f.number)
+	}
+	if !bytes.Equal(f.payload, payload) {
+		t.Fatalf("payload = %q, want %q (modern fragments have no split-size field to skip)", f.payload, payload)
+	}
+}
+
+func TestParseFragment_CompressedFieldsOnlyOnFirstFragment(t *testing.T) {
+	decompSize := uint32(4)
+	decompCRC := crc32.ChecksumIEEE([]byte("data"))
+
+	var first bytes.Buffer
+	must(binary.Write(&first, binary.LittleEndian, decompSize))
+	must(binary.Write(&first, binary.LittleEndian, decompCRC))
+	first.WriteString("pay0")
+	data0 := buildModernFragment(-1, 2, 0, first.Bytes())
+
+	f0, _, err := parseFragment(data0)
+	if err != nil {
+		t.Fatalf("parseFragment(fragment 0): %v", err)
+	}
+	if !f0.compressed || f0.decompSize != decompSize || f0.decompCRC != decompCRC {
+		t.Fatalf("fragment 0: compressed=%v decompSize=%d decompCRC=%d, want compressed=true decompSize=%d decompCRC=%d",
+			f0.compressed, f0.decompSize, f0.decompCRC, decompSize, decompCRC)
+	}
+
+	// A later fragment of the same (negative/compressed) id must not have
+	// the 8-byte decompSize/CRC header read out of its payload.
+	payload1 := []byte("pay1pay1pay1")
+	data1 := buildModernFragment(-1, 2, 1, payload1)
+	f1, _, err := parseFragment(data1)
+	if err != nil {
+		t.Fatalf("parseFragment(fragment 1): %v", err)
+	}
+	if !bytes.Equal(f1.payload, payload1) {
+		t.Fatalf("fragment 1 payload = %q, want %q (decompSize/CRC only appear on fragment 0)", f1.payload, payload1)
+	}
+}
+
+func TestReassembleFragments_OrdersByNumber(t *testing.T) {
+	fragments := map[byte]fragment{
+		1: {number: 1, total: 3, payload: []byte("world")},
+		0: {number: 0, total: 3, payload: []byte("hello ")},
+		2: {number: 2, total: 3, payload: []byte("!")},
+	}
+	got, err := reassembleFragments(fragments)
+	if err != nil {
+		t.Fatalf("reassembleFragments: %v", err)
+	}
+	if want := "hello world!"; string(got) != want {
+		t.Fatalf("reassembleFragments = %q, want %q", got, want)
+	}
+}
+
+// TestUDPSocket_ReceiveContext_WaitsAcrossReadTimeoutForFragments reproduces
+// the bug where a gap between two fragments longer than readTimeout (but
+// still well within fragmentTimeout) made receive fail with a bogus i/o
+// timeout instead of continuing to wait for the rest of the response.
+func TestUDPSocket_ReceiveContext_WaitsAcrossReadTimeoutForFragments(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	s := &udpSocket{
+		conn:            client,
+		maxFragments:    defaultMaxFragments,
+		fragmentTimeout: 2 * time.Second,
+		readTimeout:     100 * time.Millisecond,
+		writeTimeout:    defaultWriteTimeout,
+		logger:          defaultLogger,
+		tracer:          defaultTracer,
+	}
+
+	go func() {
+		_, _ = server.Write(buildModernFragment(1, 2, 0, []byte("hello ")))
+		// Longer than readTimeout (100ms), well under fragmentTimeout (2s).
+		time.Sleep(250 * time.Millisecond)
+		_, _ = server.Write(buildModernFragment(1, 2, 1, []byte("world")))
+	}()
+
+	data, err := s.receive()
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if string(data) != "hello world" {
+		t.Fatalf("receive() = %q, want %q", data, "hello world")
+	}
+}
+
+// TestUDPSocket_ReceiveContext_EnforcesFragmentTimeout checks the other
+// half of the contract: a gap that actually exceeds fragmentTimeout must
+// still fail, so the per-step fix above doesn't turn into an unbounded wait.
+func TestUDPSocket_ReceiveContext_EnforcesFragmentTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	s := &udpSocket{
+		conn:            client,
+		maxFragments:    defaultMaxFragments,
+		fragmentTimeout: 150 * time.Millisecond,
+		readTimeout:     50 * time.Millisecond,
+		writeTimeout:    defaultWriteTimeout,
+		logger:          defaultLogger,
+		tracer:          defaultTracer,
+	}
+
+	go func() {
+		_, _ = server.Write(buildModernFragment(1, 2, 0, []byte("hello ")))
+		// Longer than fragmentTimeout (150ms): reassembly must give up.
+	}()
+
+	if _, err := s.receive(); err == nil {
+		t.Fatal("receive: expected a timeout error once fragmentTimeout elapsed, got nil")
+	}
+}
@@ -18,6 +18,15 @@ var errCouldNotReadData = parseError("steam: could not read data")
 var errNotEnoughDataInResponse = parseError("steam: not enough data in response")
 var errBadData = parseError("steam: bad data in response")
 
+// must panics on a non-nil error so the read* helpers below can stay
+// single-expression; callers recover it at the boundary where a plain
+// error is expected (see the various unmarshalBinary methods).
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
 func readByte(r io.Reader) byte {
 	buf := make([]byte, 1)
 	_, err := io.ReadFull(r, buf)